@@ -0,0 +1,25 @@
+package rmsync
+
+import "io"
+
+// Backend is how Sync lists the documents already on a reMarkable and uploads new ones, without
+// caring whether the tablet is reached over its USB web UI, SSH, or the reMarkable Cloud.
+type Backend interface {
+	// ListDocuments returns the pdf documents currently present on the tablet.
+	ListDocuments() ([]RemarkableFile, error)
+	// ListFolders returns the folders (collections) currently present on the tablet.
+	ListFolders() ([]RemarkableFile, error)
+	// Upload sends the contents of r, named filename, onto the tablet.
+	Upload(filename string, r io.Reader) error
+}
+
+// DirectoryManager is implemented by backends that can organize uploaded documents into folders.
+// Sync calls it after a successful Upload when a FileToSync sets Parent and/or Tags.
+type DirectoryManager interface {
+	// EnsureDirectory resolves the UUID of the folder at the given VisibleName path (e.g.
+	// "arXiv/cs.LG"), creating any folders along the path that don't already exist.
+	EnsureDirectory(path string) (string, error)
+	// PlaceDocument rewrites the most recently uploaded document named visibleName so that it sits
+	// under parentUUID (pass "" for the root) and carries tags.
+	PlaceDocument(visibleName, parentUUID string, tags []string) error
+}
@@ -0,0 +1,127 @@
+package rmsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const cloudStorageHost string = "https://document-storage-production.cloud.remarkable.com"
+
+// CloudBackend speaks the reMarkable Cloud API, for users who want to sync without a tablet
+// tethered over USB.
+type CloudBackend struct {
+	// UserToken is the bearer token obtained from the reMarkable Cloud device-registration flow.
+	UserToken string
+}
+
+type cloudDocEntry struct {
+	ID          string `json:"ID"`
+	VisibleName string `json:"VisibleName"`
+	Type        string `json:"Type"`
+}
+
+func (b *CloudBackend) authenticate(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+b.UserToken)
+}
+
+func (b *CloudBackend) listEntries(wantType string) ([]RemarkableFile, error) {
+	req, err := http.NewRequest("GET", cloudStorageHost+"/document-storage/json/2/docs", nil)
+	if err != nil {
+		return nil, err
+	}
+	b.authenticate(req)
+
+	resp, err := myClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var entries []cloudDocEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	var files []RemarkableFile
+	for _, entry := range entries {
+		if entry.Type != wantType {
+			continue
+		}
+		files = append(files, RemarkableFile{entry.ID, entry.VisibleName})
+	}
+	return files, nil
+}
+
+func (b *CloudBackend) ListDocuments() ([]RemarkableFile, error) {
+	return b.listEntries("DocumentType")
+}
+
+func (b *CloudBackend) ListFolders() ([]RemarkableFile, error) {
+	return b.listEntries("CollectionType")
+}
+
+type cloudUploadRequest struct {
+	ID          string `json:"ID"`
+	Type        string `json:"Type"`
+	Version     int    `json:"Version"`
+	VisibleName string `json:"VisibleName"`
+}
+
+type cloudUploadResponse struct {
+	ID         string `json:"ID"`
+	BlobURLPut string `json:"BlobURLPut"`
+	Success    bool   `json:"Success"`
+}
+
+// Upload registers a new document with the reMarkable Cloud, reads r whole into memory and PUTs it
+// to the blob URL it returns. The document-storage API expects a UUID identifier, so one is generated for ID and
+// filename is only ever sent as VisibleName.
+func (b *CloudBackend) Upload(filename string, r io.Reader) error {
+	newUUID := uuid.New().String()
+	reqBody, err := json.Marshal([]cloudUploadRequest{{ID: newUUID, Type: "DocumentType", Version: 1, VisibleName: filename}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("PUT", cloudStorageHost+"/document-storage/json/2/upload/request", bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	b.authenticate(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := myClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var uploadResponses []cloudUploadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&uploadResponses); err != nil {
+		return err
+	}
+	if len(uploadResponses) == 0 || !uploadResponses[0].Success {
+		return fmt.Errorf("cloud backend refused upload request for %s", filename)
+	}
+
+	fileContents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	putReq, err := http.NewRequest("PUT", uploadResponses[0].BlobURLPut, bytes.NewReader(fileContents))
+	if err != nil {
+		return err
+	}
+	putResp, err := myClient.Do(putReq)
+	if err != nil {
+		return err
+	}
+	defer putResp.Body.Close()
+	return checkResponseStatus(putResp)
+}
@@ -0,0 +1,271 @@
+package rmsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshEnsureDirectoryMu serializes EnsureDirectory's read-check-create sequence, for the same
+// reason backend_usb.go's ensureDirectoryMu does: two concurrent calls resolving the same
+// not-yet-created path could otherwise both see "not found" and each create their own folder.
+var sshEnsureDirectoryMu sync.Mutex
+
+// SSHBackend targets a reMarkable tablet over SSH, reading its `.metadata` files directly under
+// baseDir on the device. Unlike USBBackend, this lets listing and uploading run from any host on
+// the same network as the tablet, not only from the tablet itself.
+type SSHBackend struct {
+	client *ssh.Client
+}
+
+// NewSSHBackend dials the tablet at addr (host:port, typically "10.11.99.1:22") and authenticates
+// using config.
+func NewSSHBackend(addr string, config *ssh.ClientConfig) (*SSHBackend, error) {
+	client, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return &SSHBackend{client: client}, nil
+}
+
+func (b *SSHBackend) runCommand(cmd string) ([]byte, error) {
+	session, err := b.client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	var out bytes.Buffer
+	session.Stdout = &out
+	if err := session.Run(cmd); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (b *SSHBackend) listMetadata(wantType string) ([]RemarkableFile, error) {
+	output, err := b.runCommand(fmt.Sprintf("find %s -name '*.metadata'", baseDir))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []RemarkableFile
+	for _, path := range strings.Fields(string(output)) {
+		contents, err := b.runCommand("cat " + path)
+		if err != nil {
+			return nil, err
+		}
+		var m metadata
+		if err := json.Unmarshal(contents, &m); err != nil {
+			return nil, err
+		}
+		if m.Type != wantType {
+			continue
+		}
+
+		filename := path
+		if wantType == "DocumentType" {
+			filename = strings.TrimSuffix(path, filepath.Ext(path)) + ".pdf"
+		}
+		files = append(files, RemarkableFile{filename, m.VisibleName})
+	}
+	return files, nil
+}
+
+func (b *SSHBackend) ListDocuments() ([]RemarkableFile, error) {
+	return b.listMetadata("DocumentType")
+}
+
+func (b *SSHBackend) ListFolders() ([]RemarkableFile, error) {
+	return b.listMetadata("CollectionType")
+}
+
+func (b *SSHBackend) writeRemoteFile(path string, contents []byte) error {
+	session, err := b.client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdin = bytes.NewReader(contents)
+	return session.Run(fmt.Sprintf("cat > %s", path))
+}
+
+// Upload writes a new DocumentType entry directly onto the tablet's filesystem over the SSH
+// session: the pdf itself, plus the minimal .metadata/.content pair xochitl needs to show it in
+// the library. This works from any host that can reach the tablet over SSH, unlike the USB web
+// UI that USBBackend relies on.
+func (b *SSHBackend) Upload(filename string, r io.Reader) error {
+	fileContents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	newUUID := uuid.New().String()
+	if err := b.writeRemoteFile(filepath.Join(baseDir, newUUID+".pdf"), fileContents); err != nil {
+		return err
+	}
+
+	metadataContents, err := json.Marshal(metadata{Type: "DocumentType", VisibleName: filename, Version: 1})
+	if err != nil {
+		return err
+	}
+	if err := b.writeRemoteFile(filepath.Join(baseDir, newUUID+".metadata"), metadataContents); err != nil {
+		return err
+	}
+	return b.writeRemoteFile(filepath.Join(baseDir, newUUID+".content"), []byte("{}"))
+}
+
+// sshDirEntry is a CollectionType .metadata file as found by listDirectoryEntries.
+type sshDirEntry struct {
+	uuid        string
+	visibleName string
+	parent      string
+}
+
+func (b *SSHBackend) listDirectoryEntries() ([]sshDirEntry, error) {
+	output, err := b.runCommand(fmt.Sprintf("find %s -name '*.metadata'", baseDir))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []sshDirEntry
+	for _, path := range strings.Fields(string(output)) {
+		contents, err := b.runCommand("cat " + path)
+		if err != nil {
+			return nil, err
+		}
+		var m metadata
+		if err := json.Unmarshal(contents, &m); err != nil {
+			return nil, err
+		}
+		if m.Type != "CollectionType" {
+			continue
+		}
+		entries = append(entries, sshDirEntry{
+			uuid:        strings.TrimSuffix(filepath.Base(path), ".metadata"),
+			visibleName: m.VisibleName,
+			parent:      m.Parent,
+		})
+	}
+	return entries, nil
+}
+
+// EnsureDirectory resolves the UUID of the folder at the given VisibleName path (e.g.
+// "arXiv/cs.LG"), creating any missing folders along the way, the same way
+// USBBackend.EnsureDirectory does but over the SSH session instead of the local filesystem:
+// SSHBackend reaches the tablet's on-device .metadata filesystem too, it just does so remotely.
+func (b *SSHBackend) EnsureDirectory(path string) (string, error) {
+	sshEnsureDirectoryMu.Lock()
+	defer sshEnsureDirectoryMu.Unlock()
+
+	parentUUID := ""
+	for _, segment := range directorySegments(path) {
+		entries, err := b.listDirectoryEntries()
+		if err != nil {
+			return "", err
+		}
+
+		found := ""
+		for _, entry := range entries {
+			if entry.visibleName == segment && entry.parent == parentUUID {
+				found = entry.uuid
+				break
+			}
+		}
+
+		if found == "" {
+			newUUID := uuid.New().String()
+			contents, err := json.Marshal(metadata{Type: "CollectionType", VisibleName: segment, Parent: parentUUID, Version: 1})
+			if err != nil {
+				return "", err
+			}
+			if err := b.writeRemoteFile(filepath.Join(baseDir, newUUID+".metadata"), contents); err != nil {
+				return "", err
+			}
+			if err := b.writeRemoteFile(filepath.Join(baseDir, newUUID+".content"), []byte("{}")); err != nil {
+				return "", err
+			}
+			found = newUUID
+		}
+		parentUUID = found
+	}
+	return parentUUID, nil
+}
+
+// PlaceDocument rewrites the .metadata file of the most recently modified document named
+// visibleName so that it sits under parentUUID (pass "" for the root) and carries tags. Mirrors
+// USBBackend.PlaceDocument, resolving "most recently modified" with a remote `stat` instead of
+// os.Stat.
+func (b *SSHBackend) PlaceDocument(visibleName, parentUUID string, tags []string) error {
+	output, err := b.runCommand(fmt.Sprintf("find %s -name '*.metadata'", baseDir))
+	if err != nil {
+		return err
+	}
+
+	var newest string
+	var newestModTime int64
+	for _, path := range strings.Fields(string(output)) {
+		contents, err := b.runCommand("cat " + path)
+		if err != nil {
+			return err
+		}
+		var m metadata
+		if err := json.Unmarshal(contents, &m); err != nil {
+			return err
+		}
+		if m.VisibleName != visibleName {
+			continue
+		}
+
+		statOutput, err := b.runCommand(fmt.Sprintf("stat -c %%Y %s", path))
+		if err != nil {
+			return err
+		}
+		modTime, err := strconv.ParseInt(strings.TrimSpace(string(statOutput)), 10, 64)
+		if err != nil {
+			return err
+		}
+		if newest == "" || modTime > newestModTime {
+			newestModTime = modTime
+			newest = path
+		}
+	}
+	if newest == "" {
+		return fmt.Errorf("no document named %q found to place", visibleName)
+	}
+
+	contents, err := b.runCommand("cat " + newest)
+	if err != nil {
+		return err
+	}
+	var m metadata
+	if err := json.Unmarshal(contents, &m); err != nil {
+		return err
+	}
+
+	m.Parent = parentUUID
+	if len(tags) > 0 {
+		now := time.Now().Unix()
+		m.Tags = make([]tag, 0, len(tags))
+		for _, name := range tags {
+			m.Tags = append(m.Tags, tag{Name: name, Timestamp: now})
+		}
+	}
+
+	updated, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return b.writeRemoteFile(newest, updated)
+}
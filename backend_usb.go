@@ -0,0 +1,157 @@
+package rmsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ensureDirectoryMu serializes EnsureDirectory's read-check-create sequence. Without it, two
+// concurrent calls resolving the same not-yet-created path (as Sync's worker pool does for a
+// batch of files sharing the same Parent) could both see "not found" and each synthesize their
+// own folder, leaving duplicates with the same VisibleName under the same parent.
+var ensureDirectoryMu sync.Mutex
+
+// USBBackend is the original rmsync backend: it reads `.metadata` files directly off the
+// tablet's filesystem (so it must run on-device) and uploads new documents through the tablet's
+// USB web UI at http://10.11.99.1/upload.
+type USBBackend struct{}
+
+func (USBBackend) ListDocuments() ([]RemarkableFile, error) {
+	return GetPdfFiles()
+}
+
+func (USBBackend) ListFolders() ([]RemarkableFile, error) {
+	return GetDirectoriesMetadataFiles()
+}
+
+func (USBBackend) Upload(filename string, r io.Reader) error {
+	fileContents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return UploadPdfToTablet(fileContents, filename)
+}
+
+// directorySegments splits a VisibleName path like "arXiv/cs.LG" into its folder segments,
+// ignoring any leading/trailing slashes.
+func directorySegments(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// EnsureDirectory resolves the UUID of the folder at the given VisibleName path (e.g.
+// "arXiv/cs.LG"), creating any missing folders along the way by synthesizing the
+// <uuid>.metadata and <uuid>.content files xochitl expects for a CollectionType document.
+func (USBBackend) EnsureDirectory(path string) (string, error) {
+	ensureDirectoryMu.Lock()
+	defer ensureDirectoryMu.Unlock()
+
+	parentUUID := ""
+	for _, segment := range directorySegments(path) {
+		directories, err := GetDirectoriesMetadataFiles()
+		if err != nil {
+			return "", err
+		}
+
+		found := ""
+		for _, dir := range directories {
+			contents, err := ioutil.ReadFile(dir.Filename)
+			if err != nil {
+				return "", err
+			}
+			var m metadata
+			if err := json.Unmarshal(contents, &m); err != nil {
+				return "", err
+			}
+			if m.VisibleName == segment && m.Parent == parentUUID {
+				found = strings.TrimSuffix(filepath.Base(dir.Filename), ".metadata")
+				break
+			}
+		}
+
+		if found == "" {
+			newUUID := uuid.New().String()
+			contents, err := json.Marshal(metadata{Type: "CollectionType", VisibleName: segment, Parent: parentUUID, Version: 1})
+			if err != nil {
+				return "", err
+			}
+			if err := ioutil.WriteFile(filepath.Join(baseDir, newUUID+".metadata"), contents, 0644); err != nil {
+				return "", err
+			}
+			if err := ioutil.WriteFile(filepath.Join(baseDir, newUUID+".content"), []byte("{}"), 0644); err != nil {
+				return "", err
+			}
+			found = newUUID
+		}
+		parentUUID = found
+	}
+	return parentUUID, nil
+}
+
+// PlaceDocument rewrites the .metadata file of the most recently modified document named
+// visibleName so that it sits under parentUUID (pass "" for the root) and carries tags.
+func (USBBackend) PlaceDocument(visibleName, parentUUID string, tags []string) error {
+	filenames, err := getMetadataFilenames()
+	if err != nil {
+		return err
+	}
+
+	var newest string
+	var newestModTime time.Time
+	for _, filename := range filenames {
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		var m metadata
+		if err := json.Unmarshal(contents, &m); err != nil {
+			return err
+		}
+		if m.VisibleName != visibleName {
+			continue
+		}
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(newestModTime) {
+			newestModTime = info.ModTime()
+			newest = filename
+		}
+	}
+	if newest == "" {
+		return fmt.Errorf("no document named %q found to place", visibleName)
+	}
+
+	contents, err := ioutil.ReadFile(newest)
+	if err != nil {
+		return err
+	}
+	var m metadata
+	if err := json.Unmarshal(contents, &m); err != nil {
+		return err
+	}
+
+	m.Parent = parentUUID
+	if len(tags) > 0 {
+		now := time.Now().Unix()
+		m.Tags = make([]tag, 0, len(tags))
+		for _, name := range tags {
+			m.Tags = append(m.Tags, tag{Name: name, Timestamp: now})
+		}
+	}
+
+	updated, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(newest, updated, 0644)
+}
@@ -0,0 +1,19 @@
+package rmsync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDirectorySegments(t *testing.T) {
+	cases := map[string][]string{
+		"arXiv/cs.LG": {"arXiv", "cs.LG"},
+		"/arXiv/":     {"arXiv"},
+		"dois":        {"dois"},
+	}
+	for path, want := range cases {
+		if got := directorySegments(path); !reflect.DeepEqual(got, want) {
+			t.Errorf("directorySegments(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package rmsync
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// FeedURL is the URL of an RSS or Atom feed that should be polled for new PDF enclosures.
+type FeedURL string
+
+const feedDoneFile string = "rmsync_feed_done.json"
+
+type rssFeed struct {
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title     string `xml:"title"`
+	Guid      string `xml:"guid"`
+	Enclosure struct {
+		Url  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	Links []atomLink `xml:"link"`
+}
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title string     `xml:"title"`
+	Id    string     `xml:"id"`
+	Links []atomLink `xml:"link"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+func loadDoneGuids() (map[string]struct{}, error) {
+	done := make(map[string]struct{})
+	contents, err := ioutil.ReadFile(feedDoneFile)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var guids []string
+	if err := json.Unmarshal(contents, &guids); err != nil {
+		return nil, err
+	}
+	for _, guid := range guids {
+		done[guid] = struct{}{}
+	}
+	return done, nil
+}
+
+func saveDoneGuids(done map[string]struct{}) error {
+	guids := make([]string, 0, len(done))
+	for guid := range done {
+		guids = append(guids, guid)
+	}
+	contents, err := json.Marshal(guids)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(feedDoneFile, contents, 0644)
+}
+
+// sanitizeFilename turns a feed item's title into something safe to use as a tablet filename.
+func sanitizeFilename(name string) string {
+	replacer := strings.NewReplacer("/", "-", "\\", "-", ":", "-")
+	return replacer.Replace(strings.TrimSpace(name)) + ".pdf"
+}
+
+func pdfEnclosureUrl(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "enclosure" && strings.Contains(link.Type, "pdf") {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+func fetchFeedItems(feed FeedURL) ([]FileToSync, []string, error) {
+	resp, err := myClient.Get(string(feed))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rss rssFeed
+	if err := xml.Unmarshal(contents, &rss); err == nil && len(rss.Channel.Items) > 0 {
+		var files []FileToSync
+		var guids []string
+		for _, item := range rss.Channel.Items {
+			guid := item.Guid
+			if guid == "" {
+				guid = item.Title
+			}
+			url := item.Enclosure.Url
+			if url == "" || !strings.Contains(item.Enclosure.Type, "pdf") {
+				url = pdfEnclosureUrl(item.Links)
+			}
+			if url == "" {
+				continue
+			}
+			name := item.Title
+			if name == "" {
+				name = guid
+			}
+			files = append(files, FileToSync{Filename: sanitizeFilename(name), Url: url})
+			guids = append(guids, guid)
+		}
+		return files, guids, nil
+	}
+
+	var atom atomFeed
+	if err := xml.Unmarshal(contents, &atom); err != nil {
+		return nil, nil, fmt.Errorf("could not parse feed %s as RSS or Atom: %w", feed, err)
+	}
+	var files []FileToSync
+	var guids []string
+	for _, entry := range atom.Entries {
+		guid := entry.Id
+		url := pdfEnclosureUrl(entry.Links)
+		if url == "" {
+			continue
+		}
+		name := entry.Title
+		if name == "" {
+			name = guid
+		}
+		files = append(files, FileToSync{Filename: sanitizeFilename(name), Url: url})
+		guids = append(guids, guid)
+	}
+	return files, guids, nil
+}
+
+// SyncFromFeeds polls each of the given RSS/Atom feeds for items with a PDF enclosure and syncs any
+// that have not already been processed in a previous run to the given backend. Processed GUIDs are
+// persisted to feedDoneFile so that re-runs don't re-download items the user has since deleted from
+// the tablet.
+func SyncFromFeeds(b Backend, feeds []FeedURL) error {
+	done, err := loadDoneGuids()
+	if err != nil {
+		return err
+	}
+
+	var toSync []FileToSync
+	var newGuids []string
+	for _, feed := range feeds {
+		files, guids, err := fetchFeedItems(feed)
+		if err != nil {
+			return err
+		}
+		for i, file := range files {
+			guid := guids[i]
+			if _, ok := done[guid]; ok {
+				continue
+			}
+			toSync = append(toSync, file)
+			newGuids = append(newGuids, guid)
+		}
+	}
+
+	syncErr := Sync(b, toSync, DefaultSyncOptions)
+	failed, ok := syncErr.(*MultiError)
+	if syncErr != nil && !ok {
+		return syncErr
+	}
+
+	for i, file := range toSync {
+		if ok {
+			if _, bad := failed.Errors[file.Filename]; bad {
+				continue
+			}
+		}
+		done[newGuids[i]] = struct{}{}
+	}
+
+	if err := saveDoneGuids(done); err != nil {
+		return err
+	}
+	return syncErr
+}
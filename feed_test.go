@@ -0,0 +1,24 @@
+package rmsync
+
+import "testing"
+
+func TestSanitizeFilename(t *testing.T) {
+	got := sanitizeFilename("Some: Title/With\\Slashes ")
+	want := "Some- Title-With-Slashes.pdf"
+	if got != want {
+		t.Errorf("sanitizeFilename() = %q, want %q", got, want)
+	}
+}
+
+func TestPdfEnclosureUrl(t *testing.T) {
+	links := []atomLink{
+		{Href: "https://example.com/page", Rel: "alternate", Type: "text/html"},
+		{Href: "https://example.com/paper.pdf", Rel: "enclosure", Type: "application/pdf"},
+	}
+	if got := pdfEnclosureUrl(links); got != "https://example.com/paper.pdf" {
+		t.Errorf("pdfEnclosureUrl() = %q, want the pdf enclosure link", got)
+	}
+	if got := pdfEnclosureUrl(nil); got != "" {
+		t.Errorf("pdfEnclosureUrl(nil) = %q, want empty string", got)
+	}
+}
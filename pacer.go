@@ -0,0 +1,122 @@
+package rmsync
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Pacer rate-limits and retries flaky operations, in the spirit of rclone's lib/pacer: a
+// token-bucket limiter hands out at most concurrency tokens, refilled one at a time on a fixed
+// schedule, so calls are throttled by actual throughput rather than merely capped on how many can
+// be in flight at once. A failed operation is retried with exponential backoff and jitter unless
+// its error is an HTTP status that is not transient (a 4xx other than 429); 429, 5xx, network and
+// any other errors are all retried.
+type Pacer struct {
+	tokens     chan struct{}
+	stop       chan struct{}
+	maxRetries int
+	minSleep   time.Duration
+	maxSleep   time.Duration
+}
+
+// NewPacer builds a Pacer that releases one token every minSleep (so throughput is bounded to
+// roughly one call per minSleep per token), holding at most concurrency tokens at once, and
+// retries a failed call up to maxRetries times with exponential backoff that starts at minSleep and
+// is capped at maxSleep, plus a random jitter of up to the same amount again. A non-positive
+// concurrency is floored at 1.
+func NewPacer(concurrency, maxRetries int, minSleep, maxSleep time.Duration) *Pacer {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	p := &Pacer{
+		tokens:     make(chan struct{}, concurrency),
+		stop:       make(chan struct{}),
+		maxRetries: maxRetries,
+		minSleep:   minSleep,
+		maxSleep:   maxSleep,
+	}
+	for i := 0; i < concurrency; i++ {
+		p.tokens <- struct{}{}
+	}
+	go p.refill(concurrency, minSleep)
+	return p
+}
+
+// refill drip-feeds tokens back into p.tokens at a fixed rate instead of returning one the instant
+// its Call finishes, which is what actually throttles throughput rather than just in-flight count.
+func (p *Pacer) refill(concurrency int, rate time.Duration) {
+	if rate <= 0 {
+		rate = time.Millisecond
+	}
+	ticker := time.NewTicker(rate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			select {
+			case p.tokens <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Stop releases the Pacer's background refill goroutine. Call it once the Pacer is no longer
+// needed.
+func (p *Pacer) Stop() {
+	close(p.stop)
+}
+
+// retriableStatusError wraps an HTTP status outside the 2xx/3xx range so the Pacer can tell a
+// transient failure (429, 5xx) from a permanent one (404, 401, ...).
+type retriableStatusError struct {
+	statusCode int
+	retriable  bool
+}
+
+func (e *retriableStatusError) Error() string {
+	return fmt.Sprintf("request failed with status %d", e.statusCode)
+}
+
+// checkResponseStatus turns a non-2xx/3xx HTTP response into a retriableStatusError.
+func checkResponseStatus(resp *http.Response) error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+	retriable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return &retriableStatusError{statusCode: resp.StatusCode, retriable: retriable}
+}
+
+func isRetriableError(err error) bool {
+	if rerr, ok := err.(*retriableStatusError); ok {
+		return rerr.retriable
+	}
+	return true
+}
+
+// Call runs fn, first acquiring one of the Pacer's tokens, and retries it with exponential backoff
+// and jitter while it keeps failing with a retriable error. The token consumed here is not handed
+// back when fn returns: it's only replenished by refill's ticker, which is what makes the Pacer an
+// actual throughput limiter rather than just a cap on in-flight calls.
+func (p *Pacer) Call(fn func() error) error {
+	<-p.tokens
+
+	sleep := p.minSleep
+	var err error
+	for attempt := 0; attempt <= p.maxRetries; attempt++ {
+		err = fn()
+		if err == nil || !isRetriableError(err) || attempt == p.maxRetries {
+			return err
+		}
+		time.Sleep(sleep + time.Duration(rand.Int63n(int64(sleep)+1)))
+		sleep *= 2
+		if sleep > p.maxSleep {
+			sleep = p.maxSleep
+		}
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+package rmsync
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestIsRetriableError(t *testing.T) {
+	if !isRetriableError(errors.New("boom")) {
+		t.Errorf("generic errors should be treated as retriable")
+	}
+	if isRetriableError(&retriableStatusError{statusCode: http.StatusNotFound, retriable: false}) {
+		t.Errorf("a 404 should not be retriable")
+	}
+	if !isRetriableError(&retriableStatusError{statusCode: http.StatusTooManyRequests, retriable: true}) {
+		t.Errorf("a 429 should be retriable")
+	}
+}
+
+func TestCheckResponseStatus(t *testing.T) {
+	if err := checkResponseStatus(&http.Response{StatusCode: 200}); err != nil {
+		t.Errorf("checkResponseStatus(200) = %v, want nil", err)
+	}
+
+	err := checkResponseStatus(&http.Response{StatusCode: 503})
+	if err == nil || !isRetriableError(err) {
+		t.Errorf("checkResponseStatus(503) = %v, want a retriable error", err)
+	}
+
+	err = checkResponseStatus(&http.Response{StatusCode: 404})
+	if err == nil || isRetriableError(err) {
+		t.Errorf("checkResponseStatus(404) = %v, want a non-retriable error", err)
+	}
+}
@@ -0,0 +1,20 @@
+package rmsync
+
+import "testing"
+
+func TestVisibleName(t *testing.T) {
+	got := visibleName("Doe", "2020", "Some Title")
+	want := "doe2020 - Some Title"
+	if got != want {
+		t.Errorf("visibleName() = %q, want %q", got, want)
+	}
+}
+
+func TestLastWord(t *testing.T) {
+	if got := lastWord("Jane Q. Doe"); got != "Doe" {
+		t.Errorf("lastWord() = %q, want %q", got, "Doe")
+	}
+	if got := lastWord(""); got != "" {
+		t.Errorf("lastWord(\"\") = %q, want empty string", got)
+	}
+}
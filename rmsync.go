@@ -3,13 +3,18 @@ package rmsync
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
+
+	"golang.org/x/sync/errgroup"
 )
 
 var myClient = &http.Client{Timeout: 5 * time.Minute}
@@ -24,11 +29,18 @@ type metadata struct {
 	Parent           string `json:"parent"`
 	Pinned           bool   `json:"pinned"`
 	Synced           bool   `json:"synced"`
+	Tags             []tag  `json:"tags,omitempty"`
 	Type             string `json:"type"`
 	Version          int    `json:"version"`
 	VisibleName      string `json:"visibleName"`
 }
 
+// tag is a single entry of a document's "tags" metadata array.
+type tag struct {
+	Name      string `json:"name"`
+	Timestamp int64  `json:"timestamp"`
+}
+
 // A file in the reMarkable tablet has a filename, in the form of: "<some-uuid>.<some-extension>"
 // and a visible name, which is what users see in their screens.
 type RemarkableFile struct {
@@ -42,6 +54,12 @@ type RemarkableFile struct {
 type FileToSync struct {
 	Filename string
 	Url      string
+	// Parent, if set, is the VisibleName path (e.g. "arXiv/cs.LG") of the folder the document
+	// should be placed in once uploaded. Only honoured by backends implementing DirectoryManager.
+	Parent string
+	// Tags, if set, are applied to the document once uploaded. Only honoured by backends
+	// implementing DirectoryManager.
+	Tags []string
 }
 
 func getMetadataFilenames() ([]string, error) {
@@ -123,19 +141,6 @@ func createRemarkableFileMap(files []RemarkableFile) map[string]struct{} {
 	return fileMap
 }
 
-func downloadPdfFile(url string) ([]byte, error) {
-	r, err := myClient.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer r.Body.Close()
-	fileContents, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		return nil, err
-	}
-	return fileContents, nil
-}
-
 // This functions uploads a pdf, here represented as a `[]byte` to the reMarkable. This is a low level utility,
 // ideally you should use the `Sync` function and give it a list of pdfs that you want to download.
 func UploadPdfToTablet(fileContents []byte, filename string) error {
@@ -158,35 +163,173 @@ func UploadPdfToTablet(fileContents []byte, filename string) error {
 	if err != nil {
 		return err
 	}
-	_, err = myClient.Do(req)
+	resp, err := myClient.Do(req)
 	if err != nil {
 		return err
 	}
-	return nil
+	defer resp.Body.Close()
+	return checkResponseStatus(resp)
+}
+
+// SyncOptions controls how Sync schedules and retries the downloads and uploads of a batch.
+type SyncOptions struct {
+	// Concurrency is how many files are downloaded/uploaded at once.
+	Concurrency int
+	// MaxRetries is how many times a file is retried after a retriable error (any error except an
+	// HTTP status in the 4xx range other than 429).
+	MaxRetries int
+	// MinSleep and MaxSleep bound the exponential backoff applied between retries of one file; a
+	// random jitter of up to the current backoff is added on top.
+	MinSleep time.Duration
+	MaxSleep time.Duration
+	// Progress, if set, is called after every file is attempted, successfully or not.
+	Progress func(done, total int, current FileToSync, err error)
+}
+
+// DefaultSyncOptions mirrors a conservative, serial-ish sync: a little concurrency, a handful of
+// retries, and backoff that won't hammer a flaky host.
+var DefaultSyncOptions = SyncOptions{
+	Concurrency: 4,
+	MaxRetries:  3,
+	MinSleep:    100 * time.Millisecond,
+	MaxSleep:    10 * time.Second,
+}
+
+// withSyncOptionDefaults fills in any non-positive field of opts from DefaultSyncOptions, so a
+// zero-value SyncOptions{} syncs with the default concurrency, retries and backoff rather than with
+// no retries and no backoff.
+func withSyncOptionDefaults(opts SyncOptions) SyncOptions {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultSyncOptions.Concurrency
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = DefaultSyncOptions.MaxRetries
+	}
+	if opts.MinSleep <= 0 {
+		opts.MinSleep = DefaultSyncOptions.MinSleep
+	}
+	if opts.MaxSleep <= 0 {
+		opts.MaxSleep = DefaultSyncOptions.MaxSleep
+	}
+	return opts
+}
+
+// MultiError aggregates the errors produced while syncing a batch of files, keyed by filename, so
+// that one flaky file no longer aborts files that would otherwise have synced fine.
+type MultiError struct {
+	Errors map[string]error
+}
+
+func (e *MultiError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	return fmt.Sprintf("failed to sync %d file(s): %s", len(e.Errors), strings.Join(names, ", "))
+}
+
+func downloadAndUpload(b Backend, item FileToSync) error {
+	resp, err := myClient.Get(item.Url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponseStatus(resp); err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		_, err := io.Copy(pw, resp.Body)
+		pw.CloseWithError(err)
+	}()
+
+	return b.Upload(item.Filename, pr)
 }
 
-// This function takes a list of pdf files that you want to sync. You must provide both their filenames and a public
-// URL that the tablet can use to download them. When iterating through the list of files to sync, this function
-// will first check if a file with the same name already exists, if so it will not download it again, if not it will download it
-// and then add it to the remarkable files.
-func Sync(files []FileToSync) error {
-	pdfFiles, err := GetPdfFiles()
+// placeDocument resolves item.Parent (creating folders as needed) and assigns it plus item.Tags
+// to the document just uploaded for item. It's deliberately not retried alongside the
+// download/upload: every Upload creates a new document (SSHBackend and CloudBackend mint a fresh
+// UUID on every call), so retrying it together with a flaky placement step would re-upload the PDF
+// and leave a duplicate document behind.
+func placeDocument(b Backend, item FileToSync) error {
+	if item.Parent == "" && len(item.Tags) == 0 {
+		return nil
+	}
+	dm, ok := b.(DirectoryManager)
+	if !ok {
+		return fmt.Errorf("backend does not support folder placement, but %q requested Parent/Tags", item.Filename)
+	}
+	parentUUID := ""
+	if item.Parent != "" {
+		var err error
+		parentUUID, err = dm.EnsureDirectory(item.Parent)
+		if err != nil {
+			return err
+		}
+	}
+	return dm.PlaceDocument(item.Filename, parentUUID, item.Tags)
+}
+
+// Sync takes a backend to sync to and a list of pdf files that you want to sync. You must provide
+// both their filenames and a public URL that the backend can use to download them. Files that
+// don't already exist on the backend are downloaded and uploaded concurrently, according to opts,
+// with each response body piped into the backend's Upload; the USB, SSH and Cloud backends each
+// read that body whole into memory before sending it. A file
+// that keeps failing after opts.MaxRetries does not abort the rest of the batch: every failure is
+// collected into the returned MultiError.
+func Sync(b Backend, files []FileToSync, opts SyncOptions) error {
+	opts = withSyncOptionDefaults(opts)
+
+	pdfFiles, err := b.ListDocuments()
 	if err != nil {
 		return err
 	}
 	pdfFileMap := createRemarkableFileMap(pdfFiles)
 
+	var toSync []FileToSync
 	for _, item := range files {
 		if _, ok := pdfFileMap[item.Filename]; !ok {
-			fileContents, err := downloadPdfFile(item.Url)
-			if err != nil {
-				return err
+			toSync = append(toSync, item)
+		}
+	}
+
+	pacer := NewPacer(opts.Concurrency, opts.MaxRetries, opts.MinSleep, opts.MaxSleep)
+	defer pacer.Stop()
+
+	var mu sync.Mutex
+	errs := make(map[string]error)
+	done := 0
+	total := len(toSync)
+
+	var g errgroup.Group
+	for _, item := range toSync {
+		item := item
+		g.Go(func() error {
+			err := pacer.Call(func() error {
+				return downloadAndUpload(b, item)
+			})
+			if err == nil {
+				err = placeDocument(b, item)
 			}
-			err = UploadPdfToTablet(fileContents, item.Filename)
+
+			mu.Lock()
+			done++
 			if err != nil {
-				return err
+				errs[item.Filename] = err
 			}
-		}
+			if opts.Progress != nil {
+				opts.Progress(done, total, item, err)
+			}
+			mu.Unlock()
+			return nil
+		})
+	}
+	g.Wait()
+
+	if len(errs) > 0 {
+		return &MultiError{Errors: errs}
 	}
 	return nil
 }